@@ -0,0 +1,149 @@
+package gws
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/golang/snappy"
+)
+
+// CompressionCodec is the pluggable interface behind a negotiated
+// permessage-* extension. RSV1 still signals "payload is codec-compressed"
+// on the wire; the codec just controls what "compressed" means. Built-in
+// implementations are flateCodec (the existing permessage-deflate
+// behavior) and snappyCodec; callers may register their own (e.g. zstd)
+// via Config.Codecs.
+type CompressionCodec interface {
+	// Name is the permessage-<name> token advertised in and parsed from
+	// Sec-WebSocket-Extensions, e.g. "deflate" or "snappy"
+	Name() string
+	// Compress appends the compressed form of src to dst and returns the
+	// result
+	Compress(dst, src []byte) ([]byte, error)
+	// Decompress appends the decompressed form of src to dst and returns
+	// the result, returning an error if the result would exceed maxSize
+	Decompress(dst, src []byte, maxSize int) ([]byte, error)
+	// Reset clears any retained per-connection state (e.g. a
+	// no-context-takeover flate window)
+	Reset()
+}
+
+// flateCodec adapts the existing compressor/decompressor pair to the
+// CompressionCodec interface so permessage-deflate can be selected through
+// the same negotiation path as other codecs.
+type flateCodec struct {
+	compressor   *compressor
+	decompressor *decompressor
+}
+
+func newFlateCodec(level int) *flateCodec {
+	return &flateCodec{compressor: newCompressor(level), decompressor: newDecompressor()}
+}
+
+func (c *flateCodec) Name() string { return "deflate" }
+
+func (c *flateCodec) Compress(dst, src []byte) ([]byte, error) {
+	out, err := c.compressor.Compress(src)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, out...), nil
+}
+
+func (c *flateCodec) Decompress(dst, src []byte, maxSize int) ([]byte, error) {
+	out, err := c.decompressor.Decompress(src, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, out...), nil
+}
+
+func (c *flateCodec) Reset() {
+	c.compressor = newCompressor(c.compressor.level)
+	c.decompressor = newDecompressor()
+}
+
+// snappyCodec implements CompressionCodec using github.com/golang/snappy.
+// Snappy trades compression ratio for roughly 5x the throughput of flate,
+// which suits low-latency deployments more than bandwidth-constrained
+// ones.
+type snappyCodec struct{}
+
+// NewSnappyCodec returns a CompressionCodec backed by snappy block
+// compression, suitable for Config.Codecs.
+func NewSnappyCodec() CompressionCodec { return snappyCodec{} }
+
+func (snappyCodec) Name() string { return "snappy" }
+
+func (snappyCodec) Compress(dst, src []byte) ([]byte, error) {
+	buf := make([]byte, snappy.MaxEncodedLen(len(src)))
+	out := snappy.Encode(buf, src)
+	return append(dst, out...), nil
+}
+
+func (snappyCodec) Decompress(dst, src []byte, maxSize int) ([]byte, error) {
+	n, err := snappy.DecodedLen(src)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSize {
+		return nil, io.ErrShortBuffer
+	}
+	out, err := snappy.Decode(nil, src)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, out...), nil
+}
+
+func (snappyCodec) Reset() {}
+
+// negotiateCodec picks the first codec in codecs that also appears as a
+// permessage-<name> token in extensionHeader, falling back to nil (meaning
+// "use the default flate compressor/decompressor") when none match.
+func negotiateCodec(codecs []CompressionCodec, extensionHeader string) CompressionCodec {
+	for _, codec := range codecs {
+		token := "permessage-" + codec.Name()
+		if bytes.Contains([]byte(extensionHeader), []byte(token)) {
+			return codec
+		}
+	}
+	return nil
+}
+
+// payloadCompressor is the minimal shape genFrame needs to compress a
+// frame payload; *compressor satisfies it directly, and
+// codecCompressorAdapter lets a CompressionCodec satisfy it too.
+type payloadCompressor interface {
+	Compress(src []byte) ([]byte, error)
+}
+
+// codecCompressorAdapter adapts a CompressionCodec's two-arg, append-style
+// Compress to the single-arg shape genFrame expects.
+type codecCompressorAdapter struct{ codec CompressionCodec }
+
+func (a codecCompressorAdapter) Compress(src []byte) ([]byte, error) {
+	return a.codec.Compress(nil, src)
+}
+
+// encoder returns the payloadCompressor to use for c's negotiated
+// compression: the negotiated codec when one was picked (e.g.
+// permessage-snappy), or the default flate compressor otherwise. This is
+// the single place genFrame and WriteMessage's write path should go
+// through instead of touching c.compressor directly.
+func (c *Conn) encoder() payloadCompressor {
+	if c.codec != nil {
+		return codecCompressorAdapter{c.codec}
+	}
+	return c.compressor
+}
+
+// decompress inflates payload using c's negotiated compression: the
+// negotiated codec when one was picked, or the default flate decompressor
+// otherwise. This is the read-path counterpart to encoder.
+func (c *Conn) decompress(payload []byte, maxSize int) ([]byte, error) {
+	if c.codec != nil {
+		return c.codec.Decompress(nil, payload, maxSize)
+	}
+	return c.decompressor.Decompress(payload, maxSize)
+}
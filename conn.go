@@ -17,6 +17,11 @@ type Conn struct {
 	SessionStorage SessionStorage
 	// whether to use compression
 	compressEnabled bool
+	// whether this Conn was created by Dialer.Dial rather than a server
+	// Upgrade; client-originated frames must be masked
+	isClient bool
+	// subprotocol negotiated with the peer, empty if none was
+	subprotocol string
 	// tcp connection
 	conn net.Conn
 	// server configs
@@ -33,6 +38,10 @@ type Conn struct {
 	wbuf *bufio.Writer
 	// flate compressor
 	compressor *compressor
+	// negotiated compression codec, set when the server and client agreed
+	// on an extension other than the default permessage-deflate (e.g.
+	// permessage-snappy); when nil, compressor/decompressor are used
+	codec CompressionCodec
 	// WebSocket Event Handler
 	handler Event
 
@@ -44,13 +53,17 @@ type Conn struct {
 	readQueue workerQueue
 	// async write task queue
 	writeQueue workerQueue
+	// buffers data frames for up to WriteCoalesceWindow before flushing,
+	// when configured; nil when coalescing is disabled
+	coalescer *writeCoalescer
 }
 
-func serveWebSocket(config *Config, session SessionStorage, netConn net.Conn, brw *bufio.ReadWriter, handler Event, compressEnabled bool) *Conn {
+func serveWebSocket(config *Config, session SessionStorage, netConn net.Conn, brw *bufio.ReadWriter, handler Event, compressEnabled bool, isClient bool) *Conn {
 	c := &Conn{
 		SessionStorage:  session,
 		config:          config,
 		compressEnabled: compressEnabled,
+		isClient:        isClient,
 		conn:            netConn,
 		closed:          0,
 		wbuf:            brw.Writer,
@@ -67,6 +80,10 @@ func serveWebSocket(config *Config, session SessionStorage, netConn net.Conn, br
 		c.decompressor = newDecompressor()
 	}
 
+	if config.WriteCoalesceWindow > 0 {
+		c.coalescer = newWriteCoalescer(c, config.WriteCoalesceWindow, config.WriteCoalesceMaxBytes)
+	}
+
 	return c
 }
 
@@ -179,6 +196,12 @@ func (c *Conn) RemoteAddr() net.Addr {
 	return c.conn.RemoteAddr()
 }
 
+// Subprotocol returns the subprotocol negotiated during the handshake, or
+// "" if none was requested or accepted.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
 // NetConn get tcp/tls/... conn
 func (c *Conn) NetConn() net.Conn {
 	return c.conn
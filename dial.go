@@ -0,0 +1,284 @@
+package gws
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lxzan/gws/internal"
+)
+
+// Dialer originates client-side WebSocket connections. The zero value is
+// usable and dials with no TLS config, no proxy, and no compression.
+type Dialer struct {
+	// TLSConfig is used when dialing a wss:// URL
+	TLSConfig *tls.Config
+	// NetDial, if set, is used to establish the underlying TCP connection
+	// instead of net.Dialer
+	NetDial func(network, addr string) (net.Conn, error)
+	// Proxy, if set, returns the proxy to use for a given request
+	Proxy func(*http.Request) (*url.URL, error)
+	// HandshakeTimeout bounds the HTTP Upgrade exchange; zero means no
+	// timeout
+	HandshakeTimeout time.Duration
+	// Subprotocols lists the client's supported subprotocols, in
+	// preference order
+	Subprotocols []string
+	// CompressEnabled advertises permessage-deflate support
+	CompressEnabled bool
+	// PermessageDeflate configures the permessage-deflate extension when
+	// CompressEnabled is true
+	PermessageDeflate PermessageDeflateOpts
+	// Jar, if set, is consulted for request cookies and updated from the
+	// handshake response
+	Jar http.CookieJar
+	// Config supplies the read/write async limits, compression level, and
+	// other per-connection settings that a server Upgrade would otherwise
+	// apply; a zero-value Config is used if this is nil
+	Config *Config
+	// Codecs lists additional compression codecs (e.g. snappy, zstd) to
+	// offer beyond permessage-deflate, in preference order; the first one
+	// the server also advertises is selected
+	Codecs []CompressionCodec
+}
+
+// Dial establishes a client connection to urlStr, performs the WebSocket
+// handshake, and returns a *Conn sharing the same read/write loop as a
+// server-side connection. The returned *http.Response is the handshake
+// response, so callers can inspect headers and cookies before calling
+// Listen(). handler receives events for the lifetime of the connection.
+func (d *Dialer) Dial(urlStr string, requestHeader http.Header, handler Event) (*Conn, *http.Response, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var addr string
+	var useTLS bool
+	switch u.Scheme {
+	case "ws":
+		addr = u.Host
+	case "wss":
+		addr = u.Host
+		useTLS = true
+	default:
+		return nil, nil, fmt.Errorf("gws: unsupported scheme %q", u.Scheme)
+	}
+	if !strings.Contains(addr, ":") {
+		if useTLS {
+			addr += ":443"
+		} else {
+			addr += ":80"
+		}
+	}
+
+	netConn, err := d.dialNetwork(useTLS, addr, u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if d.HandshakeTimeout > 0 {
+		_ = netConn.SetDeadline(time.Now().Add(d.HandshakeTimeout))
+	}
+
+	challengeKey, err := generateChallengeKey()
+	if err != nil {
+		_ = netConn.Close()
+		return nil, nil, err
+	}
+
+	header := internal.CloneHeader(requestHeader)
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Key", challengeKey)
+	header.Set("Sec-WebSocket-Version", "13")
+	for _, p := range d.Subprotocols {
+		header.Add("Sec-WebSocket-Protocol", p)
+	}
+	if d.CompressEnabled {
+		header.Add("Sec-WebSocket-Extensions", d.PermessageDeflate.httpHeaderValue())
+	}
+	for _, codec := range d.Codecs {
+		header.Add("Sec-WebSocket-Extensions", "permessage-"+codec.Name())
+	}
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Host:       u.Host,
+	}
+	if d.Jar != nil {
+		for _, cookie := range d.Jar.Cookies(u) {
+			req.AddCookie(cookie)
+		}
+	}
+
+	if err := req.Write(netConn); err != nil {
+		_ = netConn.Close()
+		return nil, nil, err
+	}
+
+	brw := bufio.NewReadWriter(bufio.NewReader(netConn), bufio.NewWriter(netConn))
+	resp, err := http.ReadResponse(brw.Reader, req)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		_ = netConn.Close()
+		return nil, resp, fmt.Errorf("gws: unexpected handshake status %s", resp.Status)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != internal.ComputeAcceptKey(challengeKey) {
+		_ = netConn.Close()
+		return nil, resp, fmt.Errorf("gws: invalid Sec-WebSocket-Accept")
+	}
+	if d.Jar != nil {
+		d.Jar.SetCookies(u, resp.Cookies())
+	}
+
+	extensions := resp.Header.Get("Sec-WebSocket-Extensions")
+	codec := negotiateCodec(d.Codecs, extensions)
+	compressEnabled := codec != nil
+	if !compressEnabled && d.CompressEnabled {
+		compressEnabled = d.PermessageDeflate.negotiated(extensions)
+	}
+
+	if d.HandshakeTimeout > 0 {
+		_ = netConn.SetDeadline(time.Time{})
+	}
+
+	config := d.Config
+	if config == nil {
+		config = &Config{}
+	}
+	conn := serveWebSocket(config, nil, netConn, brw, handler, compressEnabled, true)
+	conn.codec = codec
+	conn.subprotocol = acceptedSubprotocol(d.Subprotocols, resp.Header.Get("Sec-WebSocket-Protocol"))
+	return conn, resp, nil
+}
+
+// acceptedSubprotocol returns accepted if the server echoed one of the
+// subprotocols the client offered, and "" otherwise.
+func acceptedSubprotocol(offered []string, accepted string) string {
+	if accepted == "" {
+		return ""
+	}
+	for _, p := range offered {
+		if p == accepted {
+			return accepted
+		}
+	}
+	return ""
+}
+
+// dialNetwork establishes the underlying TCP connection for target, routing
+// through d.Proxy via an HTTP CONNECT tunnel when it returns a non-nil proxy
+// URL for this request, and performs the TLS handshake for wss:// either way.
+func (d *Dialer) dialNetwork(useTLS bool, addr string, target *url.URL) (net.Conn, error) {
+	dial := d.NetDial
+	if dial == nil {
+		netDialer := &net.Dialer{}
+		dial = netDialer.Dial
+	}
+
+	var netConn net.Conn
+	if d.Proxy != nil {
+		proxyURL, err := d.Proxy(&http.Request{URL: target})
+		if err != nil {
+			return nil, err
+		}
+		if proxyURL != nil {
+			netConn, err = d.dialProxy(dial, proxyURL, addr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if netConn == nil {
+		var err error
+		netConn, err = dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if useTLS {
+		tlsConfig := d.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = target.Hostname()
+		}
+		tlsConn := tls.Client(netConn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			_ = netConn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+	return netConn, nil
+}
+
+// dialProxy dials proxyURL and issues an HTTP CONNECT for addr, returning
+// the tunneled connection once the proxy reports success. proxyURL's
+// userinfo, if present, is sent as Proxy-Authorization: Basic.
+func (d *Dialer) dialProxy(dial func(network, addr string) (net.Conn, error), proxyURL *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if !strings.Contains(proxyAddr, ":") {
+		proxyAddr += ":80"
+	}
+
+	proxyConn, err := dial("tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		connectReq.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+
+	if err := connectReq.Write(proxyConn); err != nil {
+		_ = proxyConn.Close()
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(proxyConn), connectReq)
+	if err != nil {
+		_ = proxyConn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = proxyConn.Close()
+		return nil, fmt.Errorf("gws: proxy CONNECT failed: %s", resp.Status)
+	}
+	return proxyConn, nil
+}
+
+func generateChallengeKey() (string, error) {
+	var p [16]byte
+	if _, err := rand.Read(p[:]); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(p[:]), nil
+}
@@ -0,0 +1,14 @@
+//go:build !amd64 && !arm64
+
+package internal
+
+// MaskByByte applies the WebSocket masking algorithm (RFC 6455 §5.3) to
+// content in place, XORing it against the repeating 4-byte key. This is
+// the portable byte-at-a-time fallback for architectures that don't allow
+// the unaligned 64-bit word access used by the amd64/arm64 fast path in
+// mask.go.
+func MaskByByte(content []byte, key []byte) {
+	for i := 0; i < len(content); i++ {
+		content[i] ^= key[i&3]
+	}
+}
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestMaskByByte(t *testing.T) {
+	var key = [4]byte{0x12, 0x34, 0x56, 0x78}
+
+	for _, n := range []int{0, 1, 3, 4, 7, 8, 9, 15, 16, 17, 100, 1001} {
+		content := make([]byte, n)
+		rand.Read(content)
+
+		want := append([]byte(nil), content...)
+		for i := range want {
+			want[i] ^= key[i&3]
+		}
+
+		got := append([]byte(nil), content...)
+		MaskByByte(got, key[:])
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("n=%d: mismatch at byte %d: got %x want %x", n, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func BenchmarkMaskByByte(b *testing.B) {
+	var key = [4]byte{0x12, 0x34, 0x56, 0x78}
+	for _, size := range []int{64, 1024, 4096, 16384} {
+		content := make([]byte, size)
+		rand.Read(content)
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				MaskByByte(content, key[:])
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package gws
+
+import "strings"
+
+// PermessageDeflateOpts configures the permessage-deflate extension
+// (RFC 7692) offered by a Dialer when CompressEnabled is set.
+type PermessageDeflateOpts struct {
+	// ServerNoContextTakeover requests that the server not retain its
+	// compression context between messages
+	ServerNoContextTakeover bool
+	// ClientNoContextTakeover declares that the client will not retain its
+	// compression context between messages
+	ClientNoContextTakeover bool
+}
+
+// httpHeaderValue renders the Sec-WebSocket-Extensions offer for these
+// options.
+func (o PermessageDeflateOpts) httpHeaderValue() string {
+	var parts = []string{"permessage-deflate"}
+	if o.ServerNoContextTakeover {
+		parts = append(parts, "server_no_context_takeover")
+	}
+	if o.ClientNoContextTakeover {
+		parts = append(parts, "client_no_context_takeover")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// negotiated reports whether the server accepted permessage-deflate in its
+// Sec-WebSocket-Extensions response header.
+func (o PermessageDeflateOpts) negotiated(extensionHeader string) bool {
+	for _, ext := range strings.Split(extensionHeader, ",") {
+		if strings.HasPrefix(strings.TrimSpace(ext), "permessage-deflate") {
+			return true
+		}
+	}
+	return false
+}
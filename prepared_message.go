@@ -0,0 +1,165 @@
+package gws
+
+import (
+	"sync"
+
+	"github.com/lxzan/gws/internal"
+)
+
+// PreparedMessage represents a message that has been serialized into wire
+// frames ahead of time, so that broadcasting it to many connections does
+// not re-encode or re-compress the payload for each one. Because
+// server-to-client frames are never masked, the cached bytes for a given
+// (compressEnabled, RSV1) state can be written verbatim to any connection
+// negotiating that same state.
+//
+// A PreparedMessage is safe for concurrent use by multiple goroutines, and
+// is intended to be built once and reused across a hub of connections,
+// e.g. for chat rooms or pubsub fan-out.
+type PreparedMessage struct {
+	mu      sync.Mutex
+	opcode  Opcode
+	payload []byte
+
+	plainFrame      []byte
+	compressedFrame []byte
+}
+
+// NewPreparedMessage creates a PreparedMessage for the given opcode and
+// payload. The framed bytes are not computed here; they're derived lazily
+// the first time a connection in a given compression state calls
+// WritePreparedMessage, and cached from then on.
+func NewPreparedMessage(opcode Opcode, payload []byte) *PreparedMessage {
+	return &PreparedMessage{opcode: opcode, payload: payload}
+}
+
+// frame returns the cached, unmasked frame bytes matching c's negotiated
+// compression state, computing and, if necessary, compressing them on
+// first use. It's only valid for server-to-client connections; see
+// WritePreparedMessage for the client path, which can't use this cache.
+func (p *PreparedMessage) frame(c *Conn) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !c.compressEnabled {
+		if p.plainFrame == nil {
+			buf, err := genFrame(p.opcode, p.payload, false, nil, false)
+			if err != nil {
+				return nil, err
+			}
+			p.plainFrame = buf
+		}
+		return p.plainFrame, nil
+	}
+
+	if p.compressedFrame == nil {
+		// A shared PreparedMessage must not compress with a connection's
+		// live c.compressor: under context takeover that compressor's
+		// output depends on that connection's prior stream history, so
+		// the bytes wouldn't decompress correctly on any other
+		// connection, and compressing through it here would also mutate
+		// that connection's compression state out from under its normal
+		// writes. Use a fresh, one-shot compressor instead, which is
+		// equivalent to negotiating no_context_takeover for this frame.
+		codec := newCompressor(c.config.CompressLevel)
+		buf, err := genFrame(p.opcode, p.payload, true, codec, false)
+		if err != nil {
+			return nil, err
+		}
+		p.compressedFrame = buf
+	}
+	return p.compressedFrame, nil
+}
+
+// genFrame compresses payload through codec (when compress is true and
+// codec is non-nil) and frames it for opcode, producing the raw bytes that
+// would normally be written by doWrite. When isClient is set, the frame is
+// masked with a fresh key as RFC 6455 §5.1 requires for client-originated
+// frames; server-to-client frames are left unmasked.
+func genFrame(opcode Opcode, payload []byte, compress bool, codec payloadCompressor, isClient bool) ([]byte, error) {
+	if compress && codec != nil {
+		compressedPayload, err := codec.Compress(payload)
+		if err != nil {
+			return nil, err
+		}
+		payload = compressedPayload
+	}
+
+	var buf = make([]byte, 0, len(payload)+14)
+	var b0 = uint8(opcode) | 0x80 // FIN
+	if compress {
+		b0 |= 0x40 // RSV1
+	}
+	buf = append(buf, b0)
+
+	var maskBit byte
+	if isClient {
+		maskBit = 0x80
+	}
+
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, byte(n)|maskBit)
+	case n <= 65535:
+		buf = append(buf, 126|maskBit, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, 127|maskBit)
+		for i := 7; i >= 0; i-- {
+			buf = append(buf, byte(n>>(8*uint(i))))
+		}
+	}
+
+	if !isClient {
+		return append(buf, payload...), nil
+	}
+
+	key := internal.NewMaskKey()
+	buf = append(buf, key[:]...)
+	masked := append([]byte(nil), payload...)
+	internal.MaskByByte(masked, key[:])
+	return append(buf, masked...), nil
+}
+
+// WritePreparedMessage writes a previously prepared message to the
+// connection. For a server connection it skips opcode/length/mask
+// encoding and compression entirely: the raw frame bytes are cached on pm
+// for this connection's negotiated (compressEnabled, RSV1) state, making
+// broadcast to a large hub O(1) per connection instead of re-encoding the
+// message for each one. A client connection can't share that cache, since
+// each frame needs its own masking key, so it's encoded fresh on every
+// call instead. Either way, the write goes through the write coalescer
+// when one is configured, so a broadcast can't jump ahead of this
+// connection's own pending WriteMessage data.
+func (c *Conn) WritePreparedMessage(pm *PreparedMessage) error {
+	if c.isClient {
+		if c.coalescer.enabled() {
+			return c.coalescer.encodeAndAppend(pm.opcode, pm.payload, c.compressEnabled, c.encoder(), true)
+		}
+		c.wmu.Lock()
+		defer c.wmu.Unlock()
+		frame, err := genFrame(pm.opcode, pm.payload, c.compressEnabled, c.encoder(), true)
+		if err != nil {
+			return err
+		}
+		if _, err := c.wbuf.Write(frame); err != nil {
+			return err
+		}
+		return c.wbuf.Flush()
+	}
+
+	buf, err := pm.frame(c)
+	if err != nil {
+		return err
+	}
+
+	if c.coalescer.enabled() {
+		return c.coalescer.append(buf)
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := c.wbuf.Write(buf); err != nil {
+		return err
+	}
+	return c.wbuf.Flush()
+}
@@ -0,0 +1,181 @@
+package gws
+
+import (
+	"sync"
+	"time"
+)
+
+// writeCoalescer buffers framed bytes for a connection so that many small
+// WriteMessage calls can be flushed as one syscall.Write instead of one
+// per message. It's opt-in via Config.WriteCoalesceWindow /
+// WriteCoalesceMaxBytes; control frames are never buffered: they flush
+// any pending data and are written synchronously to preserve protocol
+// semantics (ping/pong/close timing must not be delayed by the window).
+type writeCoalescer struct {
+	mu       sync.Mutex
+	conn     *Conn
+	window   time.Duration
+	maxBytes int
+	pending  []byte
+	timer    *time.Timer
+}
+
+func newWriteCoalescer(c *Conn, window time.Duration, maxBytes int) *writeCoalescer {
+	return &writeCoalescer{conn: c, window: window, maxBytes: maxBytes}
+}
+
+// enabled reports whether coalescing is configured for this connection.
+func (w *writeCoalescer) enabled() bool {
+	return w != nil && w.window > 0
+}
+
+// append adds an already-framed message to the pending buffer, first
+// flushing what is pending if the frame would grow it past maxBytes. The
+// flush timer is armed when the frame starts a new batch, so no frame
+// waits longer than the window. Use this only for bytes that needed no connection-state
+// encoding (e.g. a PreparedMessage's precomputed broadcast frame); a frame
+// that still needs compressing must go through encodeAndAppend instead, so
+// encoding and enqueueing happen as one ordered step.
+func (w *writeCoalescer) append(frame []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.appendLocked(frame)
+}
+
+// encodeAndAppend frames opcode/payload through codec and appends the
+// result, all under w.mu. Compressing outside this lock would let two
+// concurrent writers race the shared, non-thread-safe per-connection
+// compressor and enqueue in a different order than they compressed,
+// producing context-takeover frames the peer can't inflate; doing the
+// encode and the enqueue as one locked step keeps compression order,
+// append order, and eventual wire order identical.
+func (w *writeCoalescer) encodeAndAppend(opcode Opcode, payload []byte, compress bool, codec payloadCompressor, isClient bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	frame, err := genFrame(opcode, payload, compress, codec, isClient)
+	if err != nil {
+		return err
+	}
+	return w.appendLocked(frame)
+}
+
+func (w *writeCoalescer) appendLocked(frame []byte) error {
+	if w.maxBytes > 0 && len(w.pending)+len(frame) > w.maxBytes {
+		if err := w.flushLocked(); err != nil {
+			return err
+		}
+	}
+
+	startsBatch := len(w.pending) == 0
+	w.pending = append(w.pending, frame...)
+
+	if !startsBatch {
+		return nil
+	}
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.window, w.onTimer)
+	} else {
+		w.timer.Reset(w.window)
+	}
+	return nil
+}
+
+func (w *writeCoalescer) onTimer() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_ = w.flushLocked()
+}
+
+func (w *writeCoalescer) flushLocked() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	w.conn.wmu.Lock()
+	_, err := w.conn.wbuf.Write(w.pending)
+	if err == nil {
+		err = w.conn.wbuf.Flush()
+	}
+	w.conn.wmu.Unlock()
+
+	w.pending = w.pending[:0]
+	return err
+}
+
+// flushAndWrite flushes any pending bytes and then writes frame, as one
+// atomic step under w.mu. This is what a control frame (ping/pong/close)
+// must go through instead of append: taking w.mu for the whole operation
+// keeps a concurrent append from slipping a data frame in between the
+// flush and the control frame, which would reorder it to arrive after a
+// close.
+func (w *writeCoalescer) flushAndWrite(frame []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+
+	w.conn.wmu.Lock()
+	defer w.conn.wmu.Unlock()
+
+	if len(w.pending) > 0 {
+		if _, err := w.conn.wbuf.Write(w.pending); err != nil {
+			return err
+		}
+		w.pending = w.pending[:0]
+	}
+	if _, err := w.conn.wbuf.Write(frame); err != nil {
+		return err
+	}
+	return w.conn.wbuf.Flush()
+}
+
+// doWrite frames opcode/payload and writes it to the connection. It's the
+// single low-level entry point for both control frames (ping/pong/close)
+// and ordinary data writes: control frames are never compressed, and
+// always flush synchronously — including any data frames already sitting
+// in the write coalescer, so a close can't overtake writes queued ahead of
+// it. Data frames go through the coalescer when one is configured.
+//
+// Encoding (compression) always happens under the same lock that decides
+// write/enqueue order for this connection — c.wmu when uncoalesced, the
+// coalescer's own mu when coalescing — so two concurrent callers can never
+// compress out of the order they end up on the wire in.
+func (c *Conn) doWrite(opcode Opcode, payload []byte) error {
+	isControl := opcode == OpcodePing || opcode == OpcodePong || opcode == OpcodeCloseConnection
+
+	if isControl {
+		frame, err := genFrame(opcode, payload, false, nil, c.isClient)
+		if err != nil {
+			return err
+		}
+		if c.coalescer.enabled() {
+			return c.coalescer.flushAndWrite(frame)
+		}
+		c.wmu.Lock()
+		defer c.wmu.Unlock()
+		if _, err := c.wbuf.Write(frame); err != nil {
+			return err
+		}
+		return c.wbuf.Flush()
+	}
+
+	compress := c.compressEnabled
+
+	if c.coalescer.enabled() {
+		return c.coalescer.encodeAndAppend(opcode, payload, compress, c.encoder(), c.isClient)
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	frame, err := genFrame(opcode, payload, compress, c.encoder(), c.isClient)
+	if err != nil {
+		return err
+	}
+	if _, err := c.wbuf.Write(frame); err != nil {
+		return err
+	}
+	return c.wbuf.Flush()
+}